@@ -0,0 +1,39 @@
+package task
+
+import "context"
+
+// cancelChannel is the well-known pub/sub channel/topic CancelBus
+// implementations use to broadcast task-cancellation requests.
+const cancelChannel = "task:cancel"
+
+// CancelBus lets a task submitted in one process be aborted from another,
+// by publishing its ID to every process subscribed to the bus.
+type CancelBus interface {
+	// Publish asks every subscriber to abort the task with the given ID.
+	Publish(ctx context.Context, taskID string) error
+
+	// Close stops the bus's subscriber goroutine and releases its resources.
+	Close() error
+}
+
+// MemoryCancelBus is a CancelBus that applies Publish directly to the local
+// Cancellations registry. It never leaves the process, so it's only useful
+// for tests and single-process setups; use RedisCancelBus to cancel tasks
+// running in other processes.
+type MemoryCancelBus struct{}
+
+// NewMemoryCancelBus returns a ready-to-use in-memory CancelBus.
+func NewMemoryCancelBus() *MemoryCancelBus {
+	return &MemoryCancelBus{}
+}
+
+// Publish aborts the local task with taskID, if one is registered.
+func (b *MemoryCancelBus) Publish(ctx context.Context, taskID string) error {
+	Cancellations.Abort(taskID)
+	return nil
+}
+
+// Close is a no-op; MemoryCancelBus holds no resources.
+func (b *MemoryCancelBus) Close() error {
+	return nil
+}
@@ -0,0 +1,101 @@
+package task
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisCancelBus is a CancelBus backed by a Redis pub/sub channel, so a task
+// submitted by one process can be aborted from any other process sharing
+// the same Redis instance.
+type RedisCancelBus struct {
+	client *redis.Client
+
+	cancel context.CancelFunc
+	doneC  chan struct{}
+}
+
+// NewRedisCancelBus starts a subscriber goroutine on the well-known
+// "task:cancel" channel and returns a ready-to-use bus. The subscriber
+// reconnects with exponential backoff if the connection to Redis drops.
+func NewRedisCancelBus(client *redis.Client) *RedisCancelBus {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b := &RedisCancelBus{
+		client: client,
+		cancel: cancel,
+		doneC:  make(chan struct{}),
+	}
+	go b.subscribe(ctx)
+
+	return b
+}
+
+// Publish asks every subscriber (including, if applicable, this process) to
+// abort the task with the given ID.
+func (b *RedisCancelBus) Publish(ctx context.Context, taskID string) error {
+	return b.client.Publish(ctx, cancelChannel, taskID).Err()
+}
+
+// Close stops the subscriber goroutine and waits for it to unsubscribe.
+func (b *RedisCancelBus) Close() error {
+	b.cancel()
+	<-b.doneC
+	return nil
+}
+
+// subscribe listens on cancelChannel until ctx is done, invoking
+// Cancellations.Abort for every task ID it receives and reconnecting with
+// exponential backoff if the subscription drops.
+func (b *RedisCancelBus) subscribe(ctx context.Context) {
+	defer close(b.doneC)
+
+	const maxBackoff = 30 * time.Second
+	backoff := time.Second
+
+	for ctx.Err() == nil {
+		pubsub := b.client.Subscribe(ctx, cancelChannel)
+
+		if _, err := pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Printf("task: redis cancel bus: subscribe failed: %v, retrying in %v", err, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+
+		backoff = time.Second // connected; reset for the next failure, if any.
+		b.receiveUntilDropped(ctx, pubsub)
+	}
+}
+
+func (b *RedisCancelBus) receiveUntilDropped(ctx context.Context, pubsub *redis.PubSub) {
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return // connection dropped; the outer loop resubscribes.
+			}
+			Cancellations.Abort(msg.Payload)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
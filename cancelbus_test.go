@@ -0,0 +1,69 @@
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCancellationsAbort(test *testing.T) {
+	t0 := New()
+
+	aborted := false
+	Cancellations.register(t0.ID, func() { aborted = true; t0.Abort() })
+	defer Cancellations.deregister(t0.ID)
+
+	if !Cancellations.Abort(t0.ID) {
+		test.Fatal("expected a registered task to be found")
+	}
+	if !aborted {
+		test.Fatal("expected Abort() to invoke the registered abort func")
+	}
+
+	if Cancellations.Abort("does-not-exist") {
+		test.Fatal("expected an unregistered task ID to report not found")
+	}
+}
+
+func TestMemoryCancelBus(test *testing.T) {
+	bus := NewMemoryCancelBus()
+	defer bus.Close()
+
+	t0 := New()
+	Cancellations.register(t0.ID, t0.Abort)
+	defer Cancellations.deregister(t0.ID)
+
+	if err := bus.Publish(context.Background(), t0.ID); err != nil {
+		test.Fatalf("Publish returned an error: %v", err)
+	}
+
+	select {
+	case <-t0.Context().Done():
+	case <-time.After(time.Second):
+		test.Fatal("expected the task to be aborted after Publish")
+	}
+}
+
+func TestPoolRegistersCancellations(test *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	pool := NewPool(PoolConfig{Concurrency: 1})
+	defer pool.Shutdown(context.Background())
+
+	pool.Register("slow", 1, func(ctx context.Context, t *T) error {
+		close(started)
+		<-release
+		return nil
+	}, nil)
+
+	t0 := New()
+	pool.Submit(t0, "slow")
+	<-started
+
+	if !Cancellations.Abort(t0.ID) {
+		test.Fatal("expected the in-flight task to be registered in Cancellations")
+	}
+	close(release)
+	t0.WaitDone()
+}
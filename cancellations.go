@@ -0,0 +1,44 @@
+package task
+
+import "sync"
+
+// Cancellations is the process-wide registry of in-flight tasks' abort
+// functions, keyed by T.ID. A Pool registers a task's Abort while it runs
+// and deregisters it once the task is done, so a CancelBus can abort the
+// right task by ID alone, regardless of which process is running it.
+var Cancellations = newCancellations()
+
+type cancellations struct {
+	mu     sync.Mutex
+	aborts map[string]func()
+}
+
+func newCancellations() *cancellations {
+	return &cancellations{aborts: make(map[string]func())}
+}
+
+func (c *cancellations) register(id string, abort func()) {
+	c.mu.Lock()
+	c.aborts[id] = abort
+	c.mu.Unlock()
+}
+
+func (c *cancellations) deregister(id string) {
+	c.mu.Lock()
+	delete(c.aborts, id)
+	c.mu.Unlock()
+}
+
+// Abort aborts the task with the given ID if it is currently registered
+// (started and not yet done) in this process. It reports whether a task
+// with that ID was found.
+func (c *cancellations) Abort(id string) bool {
+	c.mu.Lock()
+	abort, ok := c.aborts[id]
+	c.mu.Unlock()
+
+	if ok {
+		abort()
+	}
+	return ok
+}
@@ -0,0 +1,37 @@
+//go:build legacy
+
+package task
+
+// MustDone restores the pre-state-machine panicking behavior of Done(): it
+// panics instead of returning ErrInvalidTransition when the task was never
+// started, or has already reached a terminal state.
+//
+// Deprecated: handle the error Done() returns instead; this shim only
+// exists for code still written against the old panicking API.
+func (t *T) MustDone() {
+	if err := t.Done(); err != nil {
+		panic(err)
+	}
+}
+
+// MustAbort restores the pre-state-machine panicking behavior of Abort(): it
+// panics if the task has already been Cancel()ed.
+//
+// Deprecated: Abort() is now always safe to call, even on a finished or
+// canceled task; this shim only exists for code written against the old API.
+func (t *T) MustAbort() {
+	if t.State() == StateCanceled {
+		panic("aborting a canceled task")
+	}
+	t.Abort()
+}
+
+// MustWaitDone restores the pre-state-machine panicking behavior of
+// WaitDone(): it panics if the task was Cancel()ed before it ever started.
+//
+// Deprecated: use WaitDone()'s return value instead.
+func (t *T) MustWaitDone() {
+	if err := t.WaitDone(); err == ErrCanceled {
+		panic("waiting for a canceled task to be done")
+	}
+}
@@ -0,0 +1,104 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Middleware wraps a Handler to add cross-cutting behavior (logging,
+// metrics, tracing, retries, panic recovery, ...) without touching the
+// worker loop. Middlewares compose like http.Handler middleware: the
+// outermost one in a Chain runs first and decides whether/how to call the
+// next.
+type Middleware func(Handler) Handler
+
+// Chain composes mw around h, with mw[0] running outermost. The result is a
+// single Handler suitable for Pool.Register.
+func Chain(h Handler, mw ...Middleware) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Retry wraps h to re-run it on error, up to maxAttempts total attempts
+// (values <= 0 are treated as 1), waiting delay(attempt, err) between each.
+// The wait is canceled promptly if ctx is done, so an Abort() during backoff
+// doesn't block the worker for the rest of the delay. delay defaults to
+// ExponentialBackoff(100ms, 10s) if nil. t.Attempt() reports the attempt
+// currently running (starting at 1).
+func Retry(maxAttempts int, delay func(attempt int, err error) time.Duration) Middleware {
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	if delay == nil {
+		delay = ExponentialBackoff(100*time.Millisecond, 10*time.Second)
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, t *T) error {
+			var err error
+			for attempt := 1; attempt <= maxAttempts; attempt++ {
+				atomic.StoreInt32(&t.core.attempt, int32(attempt))
+
+				err = next(ctx, t)
+				if err == nil || attempt == maxAttempts {
+					return err
+				}
+
+				select {
+				case <-time.After(delay(attempt, err)):
+				case <-ctx.Done():
+					return err
+				}
+			}
+			return err
+		}
+	}
+}
+
+// ExponentialBackoff returns a delay function for Retry that doubles base
+// every attempt, capped at max, then jittered to a random value between 50%
+// and 100% of that so that many retrying tasks don't thunder back in lockstep.
+func ExponentialBackoff(base, max time.Duration) func(attempt int, err error) time.Duration {
+	return func(attempt int, err error) time.Duration {
+		d := base << uint(attempt-1)
+		if d <= 0 || d > max {
+			d = max
+		}
+		return d/2 + time.Duration(rand.Int63n(int64(d)/2+1))
+	}
+}
+
+// Recover wraps h to convert a panic during its execution into an error
+// (returned, and also stored in t.Error) instead of crashing the worker
+// goroutine.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, t *T) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("task: handler panicked: %v", r)
+					t.Error = err
+				}
+			}()
+			return next(ctx, t)
+		}
+	}
+}
+
+// Timeout wraps h so it runs with a context carrying a deadline d from when
+// it starts, in addition to (not instead of) t's own deadline; whichever
+// elapses first cancels the context the handler sees.
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, t *T) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next(ctx, t)
+		}
+	}
+}
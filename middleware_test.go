@@ -0,0 +1,108 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetrySucceedsAfterFailures(test *testing.T) {
+	var calls int
+	h := Retry(3, func(attempt int, err error) time.Duration { return time.Millisecond })(
+		func(ctx context.Context, t *T) error {
+			calls++
+			if calls < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		},
+	)
+
+	t0 := New()
+	if err := h(t0.Context(), t0); err != nil {
+		test.Fatalf("expected success on the 3rd attempt, got %v", err)
+	}
+	if calls != 3 {
+		test.Fatalf("expected 3 calls, got %d", calls)
+	}
+	if t0.Attempt() != 3 {
+		test.Fatalf("expected Attempt() == 3, got %d", t0.Attempt())
+	}
+}
+
+func TestRetryAbortsPromptlyDuringBackoff(test *testing.T) {
+	t0 := New()
+	h := Retry(5, func(attempt int, err error) time.Duration { return time.Hour })(
+		func(ctx context.Context, t *T) error {
+			return errors.New("always fails")
+		},
+	)
+
+	done := make(chan error, 1)
+	go func() { done <- h(t0.Context(), t0) }()
+
+	t0.Abort()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		test.Fatal("Retry should have returned promptly once the task was aborted, not waited out the backoff")
+	}
+}
+
+func TestRecoverConvertsPanicToError(test *testing.T) {
+	h := Recover()(func(ctx context.Context, t *T) error {
+		panic("boom")
+	})
+
+	t0 := New()
+	err := h(t0.Context(), t0)
+	if err == nil {
+		test.Fatal("expected an error from the recovered panic")
+	}
+	if t0.Error != err {
+		test.Fatalf("expected t.Error to be set to the recovered error, got %v", t0.Error)
+	}
+}
+
+func TestTimeoutCancelsHandlerContext(test *testing.T) {
+	h := Timeout(10 * time.Millisecond)(func(ctx context.Context, t *T) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	t0 := New()
+	if err := h(t0.Context(), t0); err != context.DeadlineExceeded {
+		test.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestOnStartOnDoneOnErrorHooks(test *testing.T) {
+	var started bool
+	var doneErr, reportedErr error
+	var doneCalled, errorCalled bool
+
+	t0 := New()
+	t0.OnStart(func(t *T) { started = true })
+	t0.OnDone(func(t *T, err error) { doneCalled = true; doneErr = err })
+	t0.OnError(func(t *T, err error) { errorCalled = true; reportedErr = err })
+
+	if !t0.Start() {
+		test.Fatal("Start() should have succeeded")
+	}
+	if !started {
+		test.Fatal("expected OnStart hook to run")
+	}
+
+	t0.Error = fmt.Errorf("boom")
+	t0.Done()
+
+	if !doneCalled || doneErr != t0.Error {
+		test.Fatalf("expected OnDone hook to run with %v, got called=%v err=%v", t0.Error, doneCalled, doneErr)
+	}
+	if !errorCalled || reportedErr != t0.Error {
+		test.Fatalf("expected OnError hook to run with %v, got called=%v err=%v", t0.Error, errorCalled, reportedErr)
+	}
+}
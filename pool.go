@@ -0,0 +1,338 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrPoolClosed is returned by Submit and Register once the pool has been
+// (or is being) shut down via Shutdown.
+var ErrPoolClosed = errors.New("task: pool is shut down")
+
+// Handler processes a single task dispatched from a Pool queue. ctx is
+// t.Context(), passed explicitly so Middleware can wrap it (e.g. Timeout)
+// without reaching into t. Whatever error it returns is stored on t.Error
+// before t.Done() is called.
+type Handler func(ctx context.Context, t *T) error
+
+// PoolConfig configures a Pool.
+type PoolConfig struct {
+	// Concurrency is the maximum number of Handlers running at once.
+	// Values <= 0 are treated as 1.
+	Concurrency int
+
+	// Strict enables strict-priority scheduling: a queue is only served
+	// once every queue registered with a higher priority is empty.
+	// When false (the default), queues are served in weighted round-robin
+	// proportional to their priority.
+	Strict bool
+}
+
+// Pool dispatches T's submitted to named queues to registered Handlers,
+// running up to Concurrency of them at once.
+type Pool struct {
+	cfg  PoolConfig
+	sema chan struct{}
+
+	mu     sync.Mutex
+	queues map[string]*queue
+	order  []string
+	closed bool
+	doneC  chan struct{}
+	wakeC  chan struct{}
+
+	flightMu sync.Mutex
+	inFlight map[*T]struct{}
+
+	wg sync.WaitGroup
+}
+
+// queue holds the pending tasks and scheduling state for one named queue.
+type queue struct {
+	name     string
+	priority int
+	handler  Handler
+	limiter  *rate.Limiter
+	credits  int
+
+	mu    sync.Mutex
+	items []*T
+}
+
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+func (q *queue) push(t *T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, t)
+}
+
+func (q *queue) pop() *T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return nil
+	}
+	t := q.items[0]
+	q.items = q.items[1:]
+	return t
+}
+
+func (q *queue) drain() []*T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
+
+// NewPool creates a Pool and starts its dispatch loop. Register queues with
+// Register before calling Submit.
+func NewPool(cfg PoolConfig) *Pool {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+
+	p := &Pool{
+		cfg:      cfg,
+		sema:     make(chan struct{}, cfg.Concurrency),
+		queues:   make(map[string]*queue),
+		doneC:    make(chan struct{}),
+		wakeC:    make(chan struct{}, 1),
+		inFlight: make(map[*T]struct{}),
+	}
+	go p.run()
+
+	return p
+}
+
+// Register adds a named queue to the pool. priority controls how often the
+// queue is served relative to other queues (see PoolConfig.Strict); values
+// <= 0 are treated as 1. limiter, if non-nil, throttles how often tasks are
+// dispatched from this queue.
+func (p *Pool) Register(name string, priority int, handler Handler, limiter *rate.Limiter) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+	if _, exists := p.queues[name]; exists {
+		return fmt.Errorf("task: queue %q already registered", name)
+	}
+	if priority <= 0 {
+		priority = 1
+	}
+
+	p.queues[name] = &queue{
+		name:     name,
+		priority: priority,
+		handler:  handler,
+		limiter:  limiter,
+		credits:  priority,
+	}
+	p.order = append(p.order, name)
+
+	return nil
+}
+
+// Submit enqueues t on the named queue for dispatch to its registered Handler.
+func (p *Pool) Submit(t *T, queueName string) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+	q, ok := p.queues[queueName]
+	p.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("task: unknown queue %q", queueName)
+	}
+
+	q.push(t)
+
+	select {
+	case p.wakeC <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// next picks the next queue to serve, or nil if every queue is empty.
+func (p *Pool) next() *queue {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cfg.Strict {
+		var best *queue
+		for _, name := range p.order {
+			q := p.queues[name]
+			if q.len() == 0 {
+				continue
+			}
+			if best == nil || q.priority > best.priority {
+				best = q
+			}
+		}
+		return best
+	}
+
+	anyItems := false
+	for _, name := range p.order {
+		q := p.queues[name]
+		if q.len() == 0 {
+			continue
+		}
+		anyItems = true
+		if q.credits > 0 {
+			q.credits--
+			return q
+		}
+	}
+	if !anyItems {
+		return nil
+	}
+
+	// Every non-empty queue ran out of credits this cycle: refill and retry.
+	for _, name := range p.order {
+		q := p.queues[name]
+		if q.len() > 0 {
+			q.credits = q.priority
+		}
+	}
+	for _, name := range p.order {
+		q := p.queues[name]
+		if q.len() > 0 && q.credits > 0 {
+			q.credits--
+			return q
+		}
+	}
+
+	return nil
+}
+
+func (p *Pool) trackInFlight(t *T) {
+	p.flightMu.Lock()
+	p.inFlight[t] = struct{}{}
+	p.flightMu.Unlock()
+}
+
+func (p *Pool) untrackInFlight(t *T) {
+	p.flightMu.Lock()
+	delete(p.inFlight, t)
+	p.flightMu.Unlock()
+}
+
+// run is the dispatch loop; it runs for the lifetime of the pool.
+func (p *Pool) run() {
+	for {
+		q := p.next()
+		if q == nil {
+			select {
+			case <-p.wakeC:
+				continue
+			case <-p.doneC:
+				return
+			}
+		}
+
+		t := q.pop()
+		if t == nil {
+			continue
+		}
+
+		// Track t before doing anything else: once it's popped off the
+		// queue, Shutdown's drain() of q can no longer see it, so this is
+		// the only record of it until the dispatch goroutine is actually
+		// launched below (or it's aborted and untracked on an early exit).
+		p.trackInFlight(t)
+
+		if q.limiter != nil {
+			if err := q.limiter.Wait(t.Context()); err != nil {
+				// The task was aborted/canceled while waiting for its turn.
+				p.untrackInFlight(t)
+				continue
+			}
+		}
+
+		select {
+		case p.sema <- struct{}{}:
+		case <-p.doneC:
+			// Shutdown raced us between the pop above and acquiring a
+			// slot. t was never dispatched, so abort it ourselves rather
+			// than depending on Shutdown's own inFlight sweep, which may
+			// already have run (and missed t) before trackInFlight above.
+			t.Abort()
+			p.untrackInFlight(t)
+			return
+		}
+
+		p.wg.Add(1)
+		go func(q *queue, t *T) {
+			defer p.wg.Done()
+			defer func() { <-p.sema }()
+			defer p.untrackInFlight(t)
+
+			if !t.Start() {
+				return
+			}
+			Cancellations.register(t.ID, t.Abort)
+			defer Cancellations.deregister(t.ID)
+
+			t.Error = q.handler(t.Context(), t)
+			t.Done()
+		}(q, t)
+	}
+}
+
+// Shutdown stops the pool from accepting new tasks, aborts every queued and
+// in-flight task, and waits for running Handlers to return. It returns early
+// with ctx's error if ctx is done before all Handlers have drained.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.doneC)
+	queues := make([]*queue, 0, len(p.queues))
+	for _, q := range p.queues {
+		queues = append(queues, q)
+	}
+	p.mu.Unlock()
+
+	for _, q := range queues {
+		for _, t := range q.drain() {
+			t.Abort()
+		}
+	}
+
+	p.flightMu.Lock()
+	for t := range p.inFlight {
+		t.Abort()
+	}
+	p.flightMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,150 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestPoolPriorityOrdering(test *testing.T) {
+	var mu sync.Mutex
+	var order []string
+
+	record := func(name string) Handler {
+		return func(ctx context.Context, t *T) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	pool := NewPool(PoolConfig{Concurrency: 1, Strict: true})
+	defer pool.Shutdown(context.Background())
+
+	pool.Register("high", 10, record("high"), nil)
+	pool.Register("low", 1, record("low"), nil)
+
+	// Submit to the low-priority queue first; the high-priority queue should
+	// still be drained first once it has work.
+	low1 := New()
+	low2 := New()
+	pool.Submit(low1, "low")
+	pool.Submit(low2, "low")
+
+	high1 := New()
+	pool.Submit(high1, "high")
+
+	low1.WaitDone()
+	low2.WaitDone()
+	high1.WaitDone()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 3 || order[0] != "high" {
+		test.Fatalf("expected high-priority task to run first, got %v", order)
+	}
+}
+
+func TestPoolRateLimiting(test *testing.T) {
+	var mu sync.Mutex
+	var timestamps []time.Time
+
+	pool := NewPool(PoolConfig{Concurrency: 1})
+	defer pool.Shutdown(context.Background())
+
+	limiter := rate.NewLimiter(rate.Every(50*time.Millisecond), 1)
+	pool.Register("throttled", 1, func(ctx context.Context, t *T) error {
+		mu.Lock()
+		timestamps = append(timestamps, time.Now())
+		mu.Unlock()
+		return nil
+	}, limiter)
+
+	t0, t1, t2 := New(), New(), New()
+	pool.Submit(t0, "throttled")
+	pool.Submit(t1, "throttled")
+	pool.Submit(t2, "throttled")
+
+	t0.WaitDone()
+	t1.WaitDone()
+	t2.WaitDone()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(timestamps) != 3 {
+		test.Fatalf("expected 3 dispatches, got %d", len(timestamps))
+	}
+	if gap := timestamps[2].Sub(timestamps[0]); gap < 90*time.Millisecond {
+		test.Fatalf("expected dispatches to be rate limited, total gap was %v", gap)
+	}
+}
+
+func TestPoolShutdownMidFlight(test *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	pool := NewPool(PoolConfig{Concurrency: 1})
+	pool.Register("slow", 1, func(ctx context.Context, t *T) error {
+		close(started)
+		select {
+		case <-release:
+		case <-t.Context().Done():
+		}
+		return t.Err()
+	}, nil)
+
+	inFlight := New()
+	queued := New()
+	pool.Submit(inFlight, "slow")
+	pool.Submit(queued, "slow")
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err != nil {
+		test.Fatalf("Shutdown should have returned once handlers drained, got %v", err)
+	}
+
+	if queued.Err() != ErrAborted {
+		test.Fatalf("expected queued task to be aborted, got %v", queued.Err())
+	}
+	close(release)
+}
+
+func TestPoolShutdownDuringRateLimitWait(test *testing.T) {
+	pool := NewPool(PoolConfig{Concurrency: 1})
+
+	// A limiter with its only token already spent: the next Wait() blocks
+	// for about an hour, long enough that it can only return by way of its
+	// context (queued.Context()) being canceled.
+	limiter := rate.NewLimiter(rate.Every(time.Hour), 1)
+	limiter.Allow()
+	pool.Register("throttled", 1, func(ctx context.Context, t *T) error {
+		test.Fatal("handler should never run for a task stuck in limiter.Wait")
+		return nil
+	}, limiter)
+
+	queued := New()
+	if err := pool.Submit(queued, "throttled"); err != nil {
+		test.Fatalf("Submit failed: %v", err)
+	}
+
+	// Give run() a chance to pop queued and enter limiter.Wait before we
+	// shut down; there's no externally observable signal for that moment.
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err != nil {
+		test.Fatalf("Shutdown should have returned once limiter.Wait was aborted, got %v", err)
+	}
+
+	if queued.Err() != ErrAborted {
+		test.Fatalf("expected rate-limited task to be aborted, got %v", queued.Err())
+	}
+}
@@ -1,58 +1,283 @@
 /*
 Package task provides a simple struct T that helps making intaraction with channels and goroutines simpler.
+
+T also integrates with context.Context: NewWithContext, NewWithDeadline and NewWithTimeout
+derive a T from a parent context, and Abort() cancels that context so callers can
+select on t.Context().Done() instead of (or in addition to) AbortingC().
+
+T's lifecycle is an explicit state machine (see State); its terminal transitions
+(Done, Abort, Cancel) are idempotent and never panic, so a *T is safe to hand to
+arbitrary code.
 */
 package task
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/xid"
 )
 
 // Notification is an alias of struct{}, just for making the code easy to understand.
 type Notification struct{}
 
+var (
+	// ErrAborted is the Err() of a task that ended through Abort(), including
+	// one aborted because its context's deadline elapsed or an ancestor
+	// context (including a parent T's) was canceled.
+	ErrAborted = errors.New("task: aborted")
+
+	// ErrCanceled is the Err() of a task that ended through Cancel().
+	ErrCanceled = errors.New("task: canceled")
+
+	// ErrInvalidTransition is returned by Done() called on a task that was
+	// never started or has already reached a terminal state. Abort() and
+	// Cancel() are no-ops in that case too, but return no error.
+	ErrInvalidTransition = errors.New("task: invalid state transition")
+)
+
+// State is a snapshot of where a task is in its lifecycle.
+type State int32
+
+const (
+	// StatePending is the state of a task that hasn't been Start()ed yet.
+	StatePending State = iota
+	// StateRunning is the state of a task between Start() and its terminal transition.
+	StateRunning
+	// StateDone is the state of a task whose worker finished with t.Error == nil.
+	StateDone
+	// StateFailed is the state of a task whose worker finished with t.Error != nil.
+	StateFailed
+	// StateAborted is the state of a task ended through Abort() (directly, via
+	// a deadline, or via an ancestor context/task being aborted or canceled).
+	StateAborted
+	// StateCanceled is the state of a task ended through Cancel().
+	StateCanceled
+)
+
 // T represents tasks. Use New() to create a new  instance of T.
+//
+// T is safe to copy by value, which is the established pattern for
+// embedding it into a task-specific struct (see GetTask/BulkGetTask in
+// t_test.go: &GetTask{*New(), url, ""}). All of its mutable state lives
+// behind the shared core pointer, so every copy of a T still observes and
+// guards the same single terminal transition, instead of each copy racing
+// its own doneOnce over the same underlying channels.
 type T struct {
+	// ID uniquely identifies the task within (and across) processes, so it
+	// can be looked up in Cancellations or addressed by a CancelBus.
+	ID string
+
 	// Error can hold an error that returned by the task worker.
 	Error error
 
+	core *core
+}
+
+// core holds the state a T's lifecycle is built on. It is allocated once by
+// NewWithContext and shared by pointer across every copy of the T it backs,
+// so copying a T (as the embedding pattern above does) never splits a
+// single task into two independently-terminating ones.
+type core struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	state       int32 // atomic; one of the State constants
+	startedFlag int32 // atomic; 1 once Start() has succeeded
+
+	startOnceMutex sync.Mutex // serializes the racy Start()-vs-Cancel() decision
+	doneOnce       sync.Once  // guards the single terminal transition
+
+	errMutex sync.Mutex
+	err      error // set once, inside doneOnce, read through Err()
+
 	startC    chan Notification
 	doneC     chan Notification
 	abortingC chan Notification
-	started   bool
-	done      bool
-	canceled  bool
-	aborting  bool
-	mutex     sync.Mutex
+
+	attempt int32 // atomic; current retry attempt, set by the Retry middleware
+
+	hooksMu sync.Mutex
+	onStart []func(*T)
+	onDone  []func(*T, error)
+	onError []func(*T, error)
 }
 
 // New returnes a new instance of T.
 func New() *T {
-	return &T{
+	return NewWithContext(context.Background())
+}
+
+// NewWithContext returns a new instance of T whose Context() is derived from parent.
+// Canceling parent (or any ancestor created with NewWithContext/Sub) aborts the task
+// and every task derived from it.
+func NewWithContext(parent context.Context) *T {
+	ctx, cancel := context.WithCancel(parent)
+	c := &core{
+		ctx:       ctx,
+		cancel:    cancel,
 		startC:    make(chan Notification, 1),
 		doneC:     make(chan Notification, 1),
 		abortingC: make(chan Notification, 1),
-		started:   false,
-		done:      false,
-		canceled:  false,
-		aborting:  false,
 	}
+	t := &T{
+		ID:   xid.New().String(),
+		core: c,
+	}
+
+	go func() {
+		<-ctx.Done()
+		// A no-op if the task already reached a terminal state through
+		// Done(), Abort() or Cancel(); otherwise the context was canceled by
+		// an ancestor (a parent context, or the T that Sub() was called on).
+		// This closes over c (the shared core) rather than only t, so the
+		// single doneOnce/channels it guards are still the right ones even
+		// after t has been copied by value (e.g. embedded into a GetTask)
+		// and the original *T discarded.
+		//
+		// startOnceMutex is held around finish() here for the same reason
+		// Abort() holds it: without it, a concurrent Start() could load
+		// startedFlag/IsDone() before this fires and then overwrite the
+		// StateAborted it sets, leaving the task stuck reporting
+		// StateRunning after doneC is already closed.
+		c.startOnceMutex.Lock()
+		c.finish(t, StateAborted, ErrAborted)
+		c.startOnceMutex.Unlock()
+	}()
+
+	return t
+}
+
+// NewWithDeadline returns a new instance of T that is automatically aborted once
+// d is reached, mirroring context.WithDeadline.
+func NewWithDeadline(parent context.Context, d time.Time) *T {
+	t := NewWithContext(parent)
+
+	timer := time.NewTimer(time.Until(d))
+	go func() {
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			t.Abort()
+		case <-t.core.ctx.Done():
+		}
+	}()
+
+	return t
+}
+
+// NewWithTimeout returns a new instance of T that is automatically aborted once
+// timeout elapses, mirroring context.WithTimeout.
+func NewWithTimeout(parent context.Context, timeout time.Duration) *T {
+	return NewWithDeadline(parent, time.Now().Add(timeout))
+}
+
+// Sub returns a new task whose context is derived from t's context, so t
+// reaching any terminal state (Done, Abort or Cancel) tears down every task
+// spawned through Sub() as well.
+func (t *T) Sub() *T {
+	return NewWithContext(t.core.ctx)
+}
+
+// Context returns the context associated with the task. It is canceled once
+// the task reaches any terminal state (done, failed, aborted or canceled), or
+// when its parent context is canceled.
+func (t *T) Context() context.Context {
+	return t.core.ctx
+}
+
+// Deadline returns the deadline of the task's context, which is one inherited
+// from the parent context. See context.Context.Deadline. NewWithDeadline and
+// NewWithTimeout abort the task with a timer and set no deadline on its
+// context, so the d or timeout given to them is not reported here.
+func (t *T) Deadline() (deadline time.Time, ok bool) {
+	return t.core.ctx.Deadline()
+}
+
+// State returns where the task currently is in its lifecycle.
+func (t *T) State() State {
+	return State(atomic.LoadInt32(&t.core.state))
+}
+
+// Err returns ErrAborted or ErrCanceled once the task has ended that way, the
+// worker's error (t.Error) once it has finished running, or nil while the
+// task is still pending or running.
+func (t *T) Err() error {
+	t.core.errMutex.Lock()
+	defer t.core.errMutex.Unlock()
+	return t.core.err
+}
+
+// finish performs the task's single terminal-state transition: it records
+// the state and error, cancels the task's context, and closes doneC (and,
+// for aborts, abortingC) so every WaitDone/AbortingC waiter unblocks. It is
+// safe to call from any goroutine and any number of times, and from any *T
+// that shares this core; only the first call takes effect, passing self to
+// the registered hooks. Later calls return ErrInvalidTransition.
+func (c *core) finish(self *T, state State, err error) error {
+	result := ErrInvalidTransition
+	c.doneOnce.Do(func() {
+		atomic.StoreInt32(&c.state, int32(state))
+
+		c.errMutex.Lock()
+		c.err = err
+		c.errMutex.Unlock()
+
+		c.cancel()
+		if state == StateAborted {
+			close(c.abortingC)
+		}
+		close(c.doneC)
+
+		c.hooksMu.Lock()
+		doneHooks := c.onDone
+		var errHooks []func(*T, error)
+		if err != nil {
+			errHooks = c.onError
+		}
+		c.hooksMu.Unlock()
+		for _, f := range doneHooks {
+			f(self, err)
+		}
+		for _, f := range errHooks {
+			f(self, err)
+		}
+
+		result = nil
+	})
+	return result
 }
 
 // Start marks the task as under processing by a worker.
 // Task workers must call this method before starting work on the task.
-// When the return value is false, the task has been canceled, ignore the task in the case.
+// When the return value is false, the task has already been started,
+// canceled or aborted; ignore the task in that case.
 func (t *T) Start() (ok bool) {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
+	t.core.startOnceMutex.Lock()
+	defer t.core.startOnceMutex.Unlock()
 
-	if !t.canceled {
-		t.started = true
-		t.startC <- Notification{}
+	if atomic.LoadInt32(&t.core.startedFlag) == 1 || t.IsDone() {
+		return false
 	}
 
-	return !t.canceled
+	atomic.StoreInt32(&t.core.startedFlag, 1)
+	atomic.StoreInt32(&t.core.state, int32(StateRunning))
+	t.core.startC <- Notification{}
+
+	t.core.hooksMu.Lock()
+	hooks := t.core.onStart
+	t.core.hooksMu.Unlock()
+	for _, f := range hooks {
+		f(t)
+	}
+
+	return true
 }
 
+// Do runs f if the task successfully starts, then marks the task Done().
 func (t *T) Do(f func()) {
 	if t.Start() {
 		f()
@@ -60,117 +285,134 @@ func (t *T) Do(f func()) {
 	}
 }
 
-// IsAborting returns true if the task has been requested to abort.
-// Task workers are supposed to stop working on the task once got true from this method.
+// IsAborting returns true once the task has been requested to abort, through
+// Abort(), a deadline, or an ancestor context/task being aborted or canceled.
 func (t *T) IsAborting() bool {
-	return t.aborting
+	return t.State() == StateAborted
 }
 
-// AbortingC returns a channel that will be notified when the task get a request to abort.
+// AbortingC returns a channel that is closed once the task is requested to abort.
 func (t *T) AbortingC() <-chan Notification {
-	return t.abortingC
+	return t.core.abortingC
 }
 
-// Done notifies that the processing on the task has been completed.
-// This method panics when the task has been canceled or not started yet.
-func (t *T) Done() {
-	if !t.started {
-		panic("task must be Start()ed before Done()")
+// Done notifies that the processing on the task has been completed, deriving
+// the final state from t.Error (StateFailed if non-nil, StateDone otherwise).
+// It is a no-op returning ErrInvalidTransition if the task was never started,
+// or has already reached a terminal state (e.g. it was Abort()ed).
+func (t *T) Done() error {
+	if atomic.LoadInt32(&t.core.startedFlag) == 0 {
+		return ErrInvalidTransition
 	}
-	if t.canceled {
-		panic("canceled task must not be Done()")
+
+	state := StateDone
+	if t.Error != nil {
+		state = StateFailed
 	}
-	t.done = true
-	t.doneC <- Notification{}
+	return t.core.finish(t, state, t.Error)
 }
 
 // Cancel requests the task not to be processed.
 // Returns true when the task has been canceled successfully before it's started,
 // false when it's already started and failed to cancel.
 func (t *T) Cancel() (success bool) {
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
+	t.core.startOnceMutex.Lock()
+	defer t.core.startOnceMutex.Unlock()
 
-	if t.started {
+	if atomic.LoadInt32(&t.core.startedFlag) == 1 {
 		return false
 	}
 
-	t.canceled = true
-	return true
+	return t.core.finish(t, StateCanceled, ErrCanceled) == nil
 }
 
-// Abort requests the task runner to stop working on the task.
-// This method is a non-blocking and returns immediately.
-// This method panics when the task has been canceled.
+// Abort requests the task runner to stop working on the task, putting the
+// task into StateAborted. It is a no-op if the task already reached a
+// terminal state (e.g. it already finished, or was already aborted or
+// canceled). Like Cancel(), it serializes with Start() through
+// core.startOnceMutex so a Start() already in progress can't overwrite the
+// StateAborted this sets with StateRunning; as a result Abort() can briefly
+// block on a concurrent Start().
 func (t *T) Abort() {
-	if t.canceled {
-		panic("aborting a canceled task")
-	}
-
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
-
-	t.aborting = true
-	t.abortingC <- Notification{}
+	t.core.startOnceMutex.Lock()
+	defer t.core.startOnceMutex.Unlock()
+	t.core.finish(t, StateAborted, ErrAborted)
 }
 
-// IsStarted returns true when the task is started.
-// When the task has been canceled, this method panics.
+// IsStarted returns true once Start() has succeeded for this task.
 func (t *T) IsStarted() bool {
-	if t.canceled {
-		panic("waiting for a canceled task to start")
-	}
-	return t.started
+	return atomic.LoadInt32(&t.core.startedFlag) == 1
 }
 
-// WaitStart block until the task has started.
+// WaitStart blocks until the task has started.
 // This is a blocking method. Use WaitStartC() for non-blocking wait.
-// When the task has been canceled, this method panics.
 func (t *T) WaitStart() {
-	if t.canceled {
-		panic("waiting for a canceled task to start")
-	}
-	<-t.startC
+	<-t.core.startC
 }
 
 // WaitStartC returns a channel to wait for the task to start.
 // This method is non-blocking and returns immediately.
-// When the task has been canceled, this method panics.
 func (t *T) WaitStartC() <-chan Notification {
-	if t.canceled {
-		panic("waiting for a canceled task to start")
-	}
-	return t.startC
+	return t.core.startC
 }
 
-// IsDone returns true if the task has been completed.
-// When the task has been canceled, this method panics.
+// IsDone returns true once the task has reached a terminal state (done,
+// failed, aborted or canceled).
 func (t *T) IsDone() bool {
-	if t.canceled {
-		panic("waiting for a canceled task to done")
+	switch t.State() {
+	case StateDone, StateFailed, StateAborted, StateCanceled:
+		return true
+	default:
+		return false
 	}
+}
 
-	return t.done
+// WaitDone blocks until the task has reached a terminal state, then returns
+// its Err(). Any number of goroutines may call WaitDone concurrently.
+// This is a blocking method. Use WaitDoneC() for non-blocking wait.
+func (t *T) WaitDone() error {
+	<-t.core.doneC
+	return t.Err()
 }
 
-// WaitDone blocks until the task has been Done().
-// This is a blocking method. Use WaitC() for non-blocking wait.
-// When the task has been canceled, this method panics.
-func (t *T) WaitDone() {
-	if t.canceled {
-		panic("waiting for a canceled task to be done")
-	}
+// WaitDoneC returns a channel that is closed once the task has reached a
+// terminal state, so any number of goroutines may wait on it concurrently.
+// This method is non-blocking and returns immediately.
+func (t *T) WaitDoneC() <-chan Notification {
+	return t.core.doneC
+}
 
-	<-t.doneC
+// Attempt returns the current retry attempt: 0 until a Retry-wrapped Handler
+// first runs, then 1 for the first attempt, 2 for the first retry, and so on.
+// It is only meaningful for tasks whose Handler was wrapped with Retry, which
+// sets it before each run.
+func (t *T) Attempt() int {
+	return int(atomic.LoadInt32(&t.core.attempt))
 }
 
-// WaitDoneC returns a channel to wait for the task to be done.
-// This method is non blocking and returns immediately.
-// When the task has been canceled, this method panics.
-func (t *T) WaitDoneC() <-chan Notification {
-	if t.canceled {
-		panic("waiting for a canceled task to be done")
-	}
+// OnStart registers f to run once Start() succeeds for this task. Hooks run
+// synchronously, in registration order, on the goroutine that called
+// Start(); register hooks before handing t to a worker to avoid missing one.
+func (t *T) OnStart(f func(*T)) {
+	t.core.hooksMu.Lock()
+	t.core.onStart = append(t.core.onStart, f)
+	t.core.hooksMu.Unlock()
+}
+
+// OnDone registers f to run once the task reaches any terminal state
+// (StateDone, StateFailed, StateAborted or StateCanceled). Hooks run
+// synchronously, in registration order, on the goroutine that completed the
+// transition.
+func (t *T) OnDone(f func(*T, error)) {
+	t.core.hooksMu.Lock()
+	t.core.onDone = append(t.core.onDone, f)
+	t.core.hooksMu.Unlock()
+}
 
-	return t.doneC
+// OnError registers f to run after OnDone's hooks, but only if the task
+// ended in StateFailed, StateAborted or StateCanceled.
+func (t *T) OnError(f func(*T, error)) {
+	t.core.hooksMu.Lock()
+	t.core.onError = append(t.core.onError, f)
+	t.core.hooksMu.Unlock()
 }
@@ -1,8 +1,10 @@
 package task
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -151,3 +153,70 @@ func TestT(test *testing.T) {
 	worker.Stop <- stop
 	stop.WaitDone()
 }
+
+func TestContext(test *testing.T) {
+	// Abort() cancels the task's context with ErrAborted.
+	t0 := New()
+	t0.Abort()
+	select {
+	case <-t0.Context().Done():
+	default:
+		test.Fatal("Context() should be done after Abort()")
+	}
+	if t0.Err() != ErrAborted {
+		test.Fatalf("expected ErrAborted, got %v", t0.Err())
+	}
+
+	// Canceling the parent context aborts the task.
+	ctx, cancel := context.WithCancel(context.Background())
+	t1 := NewWithContext(ctx)
+	cancel()
+	<-t1.WaitDoneC()
+	if t1.Err() != ErrAborted {
+		test.Fatalf("expected ErrAborted, got %v", t1.Err())
+	}
+
+	// Sub() tasks are torn down along with their parent.
+	parent := New()
+	child := parent.Sub()
+	parent.Abort()
+	<-child.Context().Done()
+
+	// NewWithTimeout aborts the task once the timeout elapses.
+	t2 := NewWithTimeout(context.Background(), 10*time.Millisecond)
+	<-t2.Context().Done()
+	if t2.Err() != ErrAborted {
+		test.Fatalf("expected ErrAborted after timeout, got %v", t2.Err())
+	}
+}
+
+// TestStartAbortRace checks that a Start() racing an Abort() never leaves a
+// task reporting StateRunning once doneC has been closed: whichever one
+// finish()es first must be the one Start()'s return value agrees with. This
+// is a logical-state race, not a data race (every field involved is already
+// atomic or mutex-guarded), so go test -race can't catch a regression here;
+// only running many concurrent iterations and checking the invariant can.
+func TestStartAbortRace(test *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				t0 := New()
+				go t0.Abort()
+				ok := t0.Start()
+
+				select {
+				case <-t0.WaitDoneC():
+					if ok && t0.State() == StateRunning {
+						test.Errorf("worker %d iteration %d: Start() succeeded but task was aborted; State() == StateRunning with doneC closed", i, j)
+						return
+					}
+				default:
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}